@@ -0,0 +1,126 @@
+//go:build acr
+// +build acr
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+var acrHostPattern = regexp.MustCompile(`\.azurecr\.io$`)
+
+// acrRefreshTokenTTL is the lifetime Azure grants ACR refresh tokens;
+// there is no expiry in the exchange response, so we assume the documented
+// default and refresh proactively via tokenRefreshMargin.
+const acrRefreshTokenTTL = 3 * time.Hour
+
+// ACRProvider resolves credentials for Azure Container Registry by
+// exchanging an AAD token for a short-lived ACR refresh token.
+type ACRProvider struct {
+	cache              hostTokenCache
+	tenantID, clientID string
+
+	// fetch does the actual AAD-token-for-ACR-refresh-token exchange;
+	// overridden in tests to avoid talking to Azure.
+	fetch func(ctx context.Context, tenantID, clientID, host string) (refreshToken string, err error)
+}
+
+// NewACRProvider returns a provider that authenticates against ACR using
+// the pod's AAD workload identity.
+func NewACRProvider(tenantID, clientID string) *ACRProvider {
+	return &ACRProvider{tenantID: tenantID, clientID: clientID, fetch: fetchACRRefreshToken}
+}
+
+// Matches reports whether host is an ACR registry, e.g.
+// "myregistry.azurecr.io".
+func (p *ACRProvider) Matches(host string) bool {
+	return acrHostPattern.MatchString(host)
+}
+
+// Resolve exchanges the pod's AAD token for an ACR refresh token at
+// "/oauth2/exchange", usable as the registry password with the fixed
+// "00000000-0000-0000-0000-000000000000" username ACR expects. Tokens are
+// cached per-host, since a single ACRProvider matches every *.azurecr.io
+// registry and a refresh token from one registry is not valid for another.
+func (p *ACRProvider) Resolve(ctx context.Context, host string) (*Credentials, error) {
+	if creds, ok := p.cache.cached(host, tokenRefreshMargin); ok {
+		return creds, nil
+	}
+
+	refreshToken, err := p.fetch(ctx, p.tenantID, p.clientID, host)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: acr refresh token exchange failed: %s", err)
+	}
+
+	creds := &Credentials{Username: "00000000-0000-0000-0000-000000000000", Password: refreshToken}
+	p.cache.set(host, creds, time.Now().Add(acrRefreshTokenTTL))
+	return creds, nil
+}
+
+// fetchACRRefreshToken gets an AAD access token for the ACR resource via
+// the pod's managed/workload identity, then exchanges it for an
+// ACR-scoped refresh token at host's "/oauth2/exchange" endpoint.
+func fetchACRRefreshToken(ctx context.Context, tenantID, clientID, host string) (string, error) {
+	aadToken, err := fetchAADToken(ctx, clientID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get AAD token: %s", err)
+	}
+
+	return exchangeACRRefreshToken(ctx, host, tenantID, aadToken)
+}
+
+func fetchAADToken(ctx context.Context, clientID string) (string, error) {
+	msiConfig, err := adal.NewServicePrincipalTokenFromManagedIdentity("https://management.azure.com/", &adal.ManagedIdentityOptions{
+		ClientID: clientID,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := msiConfig.EnsureFreshWithContext(ctx); err != nil {
+		return "", err
+	}
+	return msiConfig.OAuthToken(), nil
+}
+
+func exchangeACRRefreshToken(ctx context.Context, host, tenantID, aadToken string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {host},
+		"tenant":       {tenantID},
+		"access_token": {aadToken},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://%s/oauth2/exchange", host), bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from %s: %d", host, resp.StatusCode)
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.RefreshToken, nil
+}
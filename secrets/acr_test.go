@@ -0,0 +1,72 @@
+//go:build acr
+// +build acr
+
+package secrets
+
+import (
+	"context"
+	"testing"
+)
+
+func TestACRProviderMatches(t *testing.T) {
+	p := &ACRProvider{}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"myregistry.azurecr.io", true},
+		{"other.azurecr.io", true},
+		{"quay.io", false},
+		{"azurecr.io.evil.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := p.Matches(tt.host); got != tt.want {
+			t.Errorf("Matches(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestACRProviderCachesTokenPerHost(t *testing.T) {
+	calls := map[string]int{}
+	p := &ACRProvider{
+		fetch: func(ctx context.Context, tenantID, clientID, host string) (string, error) {
+			calls[host]++
+			return "refresh-" + host, nil
+		},
+	}
+
+	hostA := "a.azurecr.io"
+	hostB := "b.azurecr.io"
+
+	credsA, err := p.Resolve(context.Background(), hostA)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if credsA.Password != "refresh-"+hostA {
+		t.Errorf("unexpected refresh token for hostA: %s", credsA.Password)
+	}
+
+	// resolving the same host again must be served from cache
+	if _, err := p.Resolve(context.Background(), hostA); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a different registry, still matched by the same provider, must not
+	// reuse hostA's cached refresh token
+	credsB, err := p.Resolve(context.Background(), hostB)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if credsB.Password != "refresh-"+hostB {
+		t.Errorf("unexpected refresh token for hostB: %s", credsB.Password)
+	}
+
+	if calls[hostA] != 1 {
+		t.Errorf("expected 1 fetch for hostA, got %d", calls[hostA])
+	}
+	if calls[hostB] != 1 {
+		t.Errorf("expected 1 fetch for hostB, got %d", calls[hostB])
+	}
+}
@@ -0,0 +1,173 @@
+package secrets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rusenask/keel/types"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "keel",
+		Subsystem: "secrets_cache",
+		Name:      "hits_total",
+		Help:      "Number of secrets cache lookups served from cache.",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "keel",
+		Subsystem: "secrets_cache",
+		Name:      "misses_total",
+		Help:      "Number of secrets cache lookups that hit the underlying Getter.",
+	})
+	cacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "keel",
+		Subsystem: "secrets_cache",
+		Name:      "evictions_total",
+		Help:      "Number of cache entries removed, whether by LRU eviction, TTL expiry, or explicit invalidation.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(cacheHits, cacheMisses, cacheEvictions)
+}
+
+// Interface is implemented by anything that resolves credentials for a
+// tracked image. Both Getter and CachingGetter satisfy it.
+type Interface interface {
+	Get(trackedImage *types.TrackedImage) (*Credentials, error)
+}
+
+// cacheEntry is a cached lookup result, including negative (anonymous)
+// results so repeated anonymous checks don't keep hitting the inner Getter.
+type cacheEntry struct {
+	creds  *Credentials
+	expiry time.Time
+}
+
+// CachingGetter wraps an Interface with an LRU+TTL cache keyed on
+// (namespace, sorted secret names, registry+repository), so polling/watch
+// triggers checking many images that share a namespace+secret set don't
+// hit the Kubernetes API on every check.
+type CachingGetter struct {
+	inner Interface
+	ttl   time.Duration
+	cache *lru.Cache
+
+	mu       sync.Mutex
+	bySecret map[string]map[string]struct{} // "namespace/secretName" -> cache keys using it
+}
+
+// NewCachingGetter returns a CachingGetter wrapping inner, caching up to
+// size entries for ttl before they're considered stale.
+func NewCachingGetter(inner Interface, size int, ttl time.Duration) (*CachingGetter, error) {
+	cg := &CachingGetter{
+		inner:    inner,
+		ttl:      ttl,
+		bySecret: map[string]map[string]struct{}{},
+	}
+
+	cache, err := lru.NewWithEvict(size, cg.onEvict)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: failed to create cache: %s", err)
+	}
+	cg.cache = cache
+
+	return cg, nil
+}
+
+// Get returns cached credentials when available and unexpired, otherwise
+// falls through to the inner Getter and caches the result (including
+// anonymous results).
+func (c *CachingGetter) Get(trackedImage *types.TrackedImage) (*Credentials, error) {
+	key := cacheKeyFor(trackedImage)
+
+	c.mu.Lock()
+	if v, ok := c.cache.Get(key); ok {
+		entry := v.(cacheEntry)
+		if time.Now().Before(entry.expiry) {
+			c.mu.Unlock()
+			cacheHits.Inc()
+			return entry.creds, nil
+		}
+		c.cache.Remove(key)
+	}
+	c.mu.Unlock()
+
+	cacheMisses.Inc()
+
+	creds, err := c.inner.Get(trackedImage)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache.Add(key, cacheEntry{creds: creds, expiry: time.Now().Add(c.ttl)})
+	c.indexSecretsLocked(trackedImage.Namespace, trackedImage.Secrets, key)
+	c.mu.Unlock()
+
+	return creds, nil
+}
+
+// InvalidateSecret drops every cached entry resolved using namespace/name.
+// Wire this to the Kubernetes informer's Secret update/delete handler so a
+// secret change is reflected immediately rather than waiting out the TTL.
+func (c *CachingGetter) InvalidateSecret(namespace, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	secretKey := namespace + "/" + name
+	for key := range c.bySecret[secretKey] {
+		c.cache.Remove(key) // triggers onEvict, which tidies up bySecret
+	}
+}
+
+// onEvict is the LRU's eviction callback, invoked for LRU eviction, TTL
+// replacement, and explicit Remove() calls alike - all three count as the
+// entry no longer being cached.
+func (c *CachingGetter) onEvict(key interface{}, value interface{}) {
+	cacheEvictions.Inc()
+	c.removeFromSecretIndexLocked(key.(string))
+}
+
+// indexSecretsLocked must be called with c.mu held.
+func (c *CachingGetter) indexSecretsLocked(namespace string, secretNames []string, key string) {
+	for _, name := range secretNames {
+		secretKey := namespace + "/" + name
+		if c.bySecret[secretKey] == nil {
+			c.bySecret[secretKey] = map[string]struct{}{}
+		}
+		c.bySecret[secretKey][key] = struct{}{}
+	}
+}
+
+// removeFromSecretIndexLocked must be called with c.mu held (onEvict fires
+// synchronously from within cache.Add/cache.Remove, which already hold it).
+func (c *CachingGetter) removeFromSecretIndexLocked(key string) {
+	for secretKey, keys := range c.bySecret {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.bySecret, secretKey)
+		}
+	}
+}
+
+// cacheKeyFor builds the cache key for a tracked image: namespace, its
+// secret names (sorted, so order doesn't matter), and registry+repository.
+// It must include the repository, not just the registry host, because
+// resolution does longest-prefix matching on "registry/repository" (see
+// RegistryTarget) to support path-scoped auth entries like "quay.io/myorg"
+// - two images on the same host but under different paths can resolve to
+// different credentials and must not collapse onto the same cache entry.
+func cacheKeyFor(trackedImage *types.TrackedImage) string {
+	secretNames := append([]string(nil), trackedImage.Secrets...)
+	sort.Strings(secretNames)
+
+	return trackedImage.Namespace + "|" + strings.Join(secretNames, ",") + "|" + RegistryTarget(trackedImage)
+}
@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rusenask/keel/types"
+	"github.com/rusenask/keel/util/image"
+)
+
+// countingGetter is a minimal Interface implementation that counts calls,
+// standing in for the underlying Getter (and, transitively, the
+// FakeK8sImplementer it would otherwise hit) so these tests can assert on
+// call counts without depending on testutil internals.
+type countingGetter struct {
+	calls int
+	creds *Credentials
+}
+
+func (c *countingGetter) Get(trackedImage *types.TrackedImage) (*Credentials, error) {
+	c.calls++
+	return c.creds, nil
+}
+
+func cachingTestImage() *types.TrackedImage {
+	imgRef, _ := image.Parse("karolisr/webhook-demo:0.0.11")
+	return &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+}
+
+func TestCachingGetterCachesRepeatedLookups(t *testing.T) {
+	inner := &countingGetter{creds: &Credentials{Username: "user-x", Password: "pass-x"}}
+
+	cg, err := NewCachingGetter(inner, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create caching getter: %s", err)
+	}
+
+	trackedImage := cachingTestImage()
+
+	for i := 0; i < 3; i++ {
+		creds, err := cg.Get(trackedImage)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if creds.Username != "user-x" {
+			t.Errorf("unexpected username: %s", creds.Username)
+		}
+	}
+
+	if inner.calls != 1 {
+		t.Errorf("expected exactly 1 call to the underlying getter, got %d", inner.calls)
+	}
+}
+
+func TestCachingGetterInvalidateSecret(t *testing.T) {
+	inner := &countingGetter{creds: &Credentials{Username: "user-x"}}
+
+	cg, err := NewCachingGetter(inner, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create caching getter: %s", err)
+	}
+
+	trackedImage := cachingTestImage()
+
+	if _, err := cg.Get(trackedImage); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := cg.Get(trackedImage); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected 1 call before invalidation, got %d", inner.calls)
+	}
+
+	// simulates the k8s informer observing a Secret update/delete
+	cg.InvalidateSecret("default", "myregistrysecret")
+
+	if _, err := cg.Get(trackedImage); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected invalidation to force a fresh lookup, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingGetterKeysByRepositoryNotJustRegistry(t *testing.T) {
+	responses := map[string]*Credentials{
+		"quay.io/myorg":    {Username: "myorg-user", Password: "myorg-pass"},
+		"quay.io/otherorg": {Username: "otherorg-user", Password: "otherorg-pass"},
+	}
+
+	inner := &perRepoGetter{responses: responses}
+
+	cg, err := NewCachingGetter(inner, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create caching getter: %s", err)
+	}
+
+	myorgImg, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+	otherorgImg, _ := image.Parse("quay.io/otherorg/webhook-demo:0.0.11")
+
+	myorgTracked := &types.TrackedImage{Image: myorgImg, Namespace: "default", Secrets: []string{"sharedsecret"}}
+	otherorgTracked := &types.TrackedImage{Image: otherorgImg, Namespace: "default", Secrets: []string{"sharedsecret"}}
+
+	creds, err := cg.Get(myorgTracked)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creds.Username != "myorg-user" {
+		t.Errorf("unexpected username for myorg image: %s", creds.Username)
+	}
+
+	// different repository under the same registry host and same secret
+	// list must not be served myorg's cached creds
+	creds, err = cg.Get(otherorgTracked)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creds.Username != "otherorg-user" {
+		t.Errorf("expected distinct cache entry for otherorg repository, got username: %s", creds.Username)
+	}
+
+	if inner.calls != 2 {
+		t.Errorf("expected one inner lookup per distinct repository, got %d", inner.calls)
+	}
+}
+
+// perRepoGetter returns different credentials depending on the resolved
+// registry+repository target, used to prove cache keys are scoped by
+// repository and not just registry host.
+type perRepoGetter struct {
+	calls     int
+	responses map[string]*Credentials
+}
+
+func (g *perRepoGetter) Get(trackedImage *types.TrackedImage) (*Credentials, error) {
+	g.calls++
+	return g.responses[RegistryTarget(trackedImage)], nil
+}
+
+func TestCachingGetterTTLExpiry(t *testing.T) {
+	inner := &countingGetter{creds: &Credentials{Username: "user-x"}}
+
+	cg, err := NewCachingGetter(inner, 10, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create caching getter: %s", err)
+	}
+
+	trackedImage := cachingTestImage()
+
+	if _, err := cg.Get(trackedImage); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := cg.Get(trackedImage); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if inner.calls != 2 {
+		t.Errorf("expected TTL expiry to force a fresh lookup, got %d calls", inner.calls)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build ecr
+// +build ecr
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+var ecrHostPattern = regexp.MustCompile(`^\d+\.dkr\.ecr\.([^.]+)\.amazonaws\.com$`)
+
+// ECRProvider resolves credentials for AWS ECR registries using the pod's
+// ambient AWS identity (IRSA or instance profile, via IMDSv2) instead of a
+// static imagePullSecret.
+type ECRProvider struct {
+	cache hostTokenCache
+	sess  *session.Session
+
+	// fetch does the actual ecr:GetAuthorizationToken call; overridden in
+	// tests to avoid talking to AWS.
+	fetch func(ctx context.Context, sess *session.Session, region string) (username, password string, expiry time.Time, err error)
+}
+
+// NewECRProvider returns a provider that authenticates against ECR using
+// whatever AWS credential chain is available in the environment.
+func NewECRProvider() (*ECRProvider, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("secrets: failed to create AWS session: %s", err)
+	}
+	return &ECRProvider{sess: sess, fetch: fetchECRToken}, nil
+}
+
+// Matches reports whether host is an ECR registry, e.g.
+// "123456789012.dkr.ecr.us-east-1.amazonaws.com".
+func (p *ECRProvider) Matches(host string) bool {
+	return ecrHostPattern.MatchString(host)
+}
+
+// Resolve exchanges the pod's AWS identity for an ECR authorization token
+// scoped to host's region. Tokens are cached per-host, since a single
+// ECRProvider matches every region and a token from one region is not
+// valid for another.
+func (p *ECRProvider) Resolve(ctx context.Context, host string) (*Credentials, error) {
+	if creds, ok := p.cache.cached(host, tokenRefreshMargin); ok {
+		return creds, nil
+	}
+
+	match := ecrHostPattern.FindStringSubmatch(host)
+	if match == nil {
+		return nil, fmt.Errorf("secrets: %s is not an ECR host", host)
+	}
+
+	username, password, expiry, err := p.fetch(ctx, p.sess, match[1])
+	if err != nil {
+		return nil, fmt.Errorf("secrets: ecr:GetAuthorizationToken failed: %s", err)
+	}
+
+	creds := &Credentials{Username: username, Password: password}
+	p.cache.set(host, creds, expiry)
+	return creds, nil
+}
+
+// fetchECRToken calls ecr:GetAuthorizationToken in region and decodes the
+// returned base64 "user:pass" token.
+func fetchECRToken(ctx context.Context, sess *session.Session, region string) (string, string, time.Time, error) {
+	svc := ecr.New(sess, aws.NewConfig().WithRegion(region))
+	out, err := svc.GetAuthorizationTokenWithContext(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if len(out.AuthorizationData) == 0 {
+		return "", "", time.Time{}, fmt.Errorf("no authorization data returned")
+	}
+
+	data := out.AuthorizationData[0]
+	username, password, err := decodeBase64Secret(aws.StringValue(data.AuthorizationToken))
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to decode ecr authorization token: %s", err)
+	}
+
+	return username, password, aws.TimeValue(data.ExpiresAt), nil
+}
@@ -0,0 +1,76 @@
+//go:build ecr
+// +build ecr
+
+package secrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestECRProviderMatches(t *testing.T) {
+	p := &ECRProvider{}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com", true},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com", true},
+		{"quay.io", false},
+		{"gcr.io", false},
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com.evil.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := p.Matches(tt.host); got != tt.want {
+			t.Errorf("Matches(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestECRProviderCachesTokenPerHost(t *testing.T) {
+	calls := map[string]int{}
+	p := &ECRProvider{
+		fetch: func(ctx context.Context, sess *session.Session, region string) (string, string, time.Time, error) {
+			calls[region]++
+			return "AWS", "token-" + region, time.Now().Add(time.Hour), nil
+		},
+	}
+
+	hostA := "111111111111.dkr.ecr.us-east-1.amazonaws.com"
+	hostB := "222222222222.dkr.ecr.eu-west-1.amazonaws.com"
+
+	credsA, err := p.Resolve(context.Background(), hostA)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if credsA.Password != "token-us-east-1" {
+		t.Errorf("unexpected token for hostA: %s", credsA.Password)
+	}
+
+	// resolving the same host again must be served from cache
+	if _, err := p.Resolve(context.Background(), hostA); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a different region, still matched by the same provider, must not
+	// reuse hostA's cached token
+	credsB, err := p.Resolve(context.Background(), hostB)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if credsB.Password != "token-eu-west-1" {
+		t.Errorf("unexpected token for hostB: %s", credsB.Password)
+	}
+
+	if calls["us-east-1"] != 1 {
+		t.Errorf("expected 1 fetch for us-east-1, got %d", calls["us-east-1"])
+	}
+	if calls["eu-west-1"] != 1 {
+		t.Errorf("expected 1 fetch for eu-west-1, got %d", calls["eu-west-1"])
+	}
+}
@@ -0,0 +1,70 @@
+//go:build gcr
+// +build gcr
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+var gcrHostPattern = regexp.MustCompile(`(^|\.)gcr\.io$|-docker\.pkg\.dev$`)
+
+// GCRProvider resolves credentials for GCR/Artifact Registry by exchanging
+// the pod's GKE workload-identity token for a registry access token. The
+// token isn't host-scoped (one token is valid for every GCR/AR host), so a
+// single cached entry is shared across matches, unlike ECR/ACR.
+type GCRProvider struct {
+	cache providerCache
+
+	// fetch gets the GCP access token; overridden in tests to avoid
+	// talking to GCP.
+	fetch func(ctx context.Context) (accessToken string, expiry time.Time, err error)
+}
+
+// NewGCRProvider returns a provider that authenticates using the ambient
+// GCP workload identity bound to the pod's service account.
+func NewGCRProvider() *GCRProvider {
+	return &GCRProvider{fetch: fetchGCRToken}
+}
+
+// Matches reports whether host is a GCR or Artifact Registry host, e.g.
+// "gcr.io" or "us-docker.pkg.dev".
+func (p *GCRProvider) Matches(host string) bool {
+	return gcrHostPattern.MatchString(host)
+}
+
+// Resolve exchanges the pod's workload identity for an
+// "oauth2accesstoken"-authenticated registry token.
+func (p *GCRProvider) Resolve(ctx context.Context, host string) (*Credentials, error) {
+	if creds, ok := p.cache.cached(tokenRefreshMargin); ok {
+		return creds, nil
+	}
+
+	accessToken, expiry, err := p.fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: failed to fetch GCP access token: %s", err)
+	}
+
+	creds := &Credentials{Username: "oauth2accesstoken", Password: accessToken}
+	p.cache.set(creds, expiry)
+	return creds, nil
+}
+
+func fetchGCRToken(ctx context.Context) (string, time.Time, error) {
+	ts, err := google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/devstorage.read_only")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := ts.Token()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token.AccessToken, token.Expiry, nil
+}
@@ -0,0 +1,56 @@
+//go:build gcr
+// +build gcr
+
+package secrets
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGCRProviderMatches(t *testing.T) {
+	p := &GCRProvider{}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"gcr.io", true},
+		{"us.gcr.io", true},
+		{"eu.gcr.io", true},
+		{"us-docker.pkg.dev", true},
+		{"quay.io", false},
+		{"notgcr.io.evil.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := p.Matches(tt.host); got != tt.want {
+			t.Errorf("Matches(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestGCRProviderCachesToken(t *testing.T) {
+	calls := 0
+	p := &GCRProvider{
+		fetch: func(ctx context.Context) (string, time.Time, error) {
+			calls++
+			return "access-token", time.Now().Add(time.Hour), nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		creds, err := p.Resolve(context.Background(), "gcr.io")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if creds.Username != "oauth2accesstoken" || creds.Password != "access-token" {
+			t.Errorf("unexpected creds: %+v", creds)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected a single token fetch across repeated resolves, got %d", calls)
+	}
+}
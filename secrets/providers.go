@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CredentialProvider resolves registry credentials via a cloud workload
+// identity mechanism (IRSA, GKE workload identity, AAD), rather than a
+// Kubernetes imagePullSecret. A Getter tries matching providers before
+// falling back to Kubernetes secrets.
+type CredentialProvider interface {
+	// Matches reports whether this provider handles the given registry host.
+	Matches(host string) bool
+	// Resolve fetches, or returns a cached, token for host.
+	Resolve(ctx context.Context, host string) (*Credentials, error)
+}
+
+// tokenRefreshMargin is how long before expiry a cached cloud token is
+// treated as stale and refreshed ahead of use.
+const tokenRefreshMargin = 2 * time.Minute
+
+// providerCache is embedded by CredentialProvider implementations to keep
+// a resolved token in-memory until shortly before it expires.
+type providerCache struct {
+	mu     sync.Mutex
+	creds  *Credentials
+	expiry time.Time
+}
+
+// cached returns the cached credentials if they remain valid for at least
+// the given safety margin.
+func (c *providerCache) cached(margin time.Duration) (*Credentials, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.creds == nil || time.Now().Add(margin).After(c.expiry) {
+		return nil, false
+	}
+	return c.creds, true
+}
+
+func (c *providerCache) set(creds *Credentials, expiry time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.creds = creds
+	c.expiry = expiry
+}
+
+// hostTokenCache caches one token per host. Unlike providerCache, it's for
+// providers whose credentials are scoped to the specific host resolved
+// (e.g. an ECR token is only valid for the region it was issued for, an
+// ACR refresh token only for the registry it names) - a single registered
+// provider instance matches every host its regex accepts, so a single
+// shared token would otherwise be served across unrelated hosts.
+type hostTokenCache struct {
+	mu      sync.Mutex
+	entries map[string]*providerCache
+}
+
+func (c *hostTokenCache) cached(host string, margin time.Duration) (*Credentials, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return entry.cached(margin)
+}
+
+func (c *hostTokenCache) set(host string, creds *Credentials, expiry time.Time) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[string]*providerCache{}
+	}
+	entry, ok := c.entries[host]
+	if !ok {
+		entry = &providerCache{}
+		c.entries[host] = entry
+	}
+	c.mu.Unlock()
+
+	entry.set(creds, expiry)
+}
+
+// WithCredentialProviders registers cloud credential providers that are
+// tried, in order, before falling back to Kubernetes imagePullSecrets.
+func WithCredentialProviders(providers ...CredentialProvider) GetterOption {
+	return func(g *Getter) {
+		g.providers = append(g.providers, providers...)
+	}
+}
+
+// resolveProvider tries every registered CredentialProvider for host,
+// returning the first match's credentials.
+func (g *Getter) resolveProvider(host string) (*Credentials, bool) {
+	for _, provider := range g.providers {
+		if !provider.Matches(host) {
+			continue
+		}
+
+		creds, err := provider.Resolve(context.Background(), host)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"host":  host,
+			}).Warn("secrets.Getter: cloud credential provider failed")
+			continue
+		}
+		return creds, true
+	}
+	return nil, false
+}
@@ -0,0 +1,503 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rusenask/keel/k8s"
+	"github.com/rusenask/keel/types"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	dockerConfigJSONKey = ".dockerconfigjson"
+	dockerCfgKey        = ".dockercfg"
+
+	// defaultHelperTimeout bounds how long we wait for a docker-credential-*
+	// helper binary to respond before treating it as unavailable.
+	defaultHelperTimeout = 5 * time.Second
+)
+
+// Credentials holds a resolved username/password pair for a registry.
+// Zero value represents anonymous access.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// dockerConfigEntry is a single registry entry, as found either nested
+// under "auths" (docker config.json) or directly keyed by host
+// (legacy .dockercfg).
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// dockerConfigJSON mirrors the "auths" wrapper used by docker config.json.
+type dockerConfigJSON struct {
+	Auths       map[string]dockerConfigEntry `json:"auths"`
+	CredHelpers map[string]string            `json:"credHelpers,omitempty"`
+	CredsStore  string                       `json:"credsStore,omitempty"`
+}
+
+// GetterOption configures optional behaviour of a Getter.
+type GetterOption func(*Getter)
+
+// WithHelperPath overrides where credential helper binaries
+// (docker-credential-<name>) are looked up. Defaults to $PATH.
+func WithHelperPath(path string) GetterOption {
+	return func(g *Getter) {
+		g.helperPath = path
+	}
+}
+
+// WithHelperTimeout bounds how long a single helper invocation is allowed
+// to run for.
+func WithHelperTimeout(timeout time.Duration) GetterOption {
+	return func(g *Getter) {
+		g.helperTimeout = timeout
+	}
+}
+
+// WithHelperSoftFail sets whether a missing/failing credential helper is
+// treated as a soft failure. Get, GetAll and ResolveFromConfig never return
+// a helper failure as an error, with or without this option: a failed
+// helper lookup falls through to the next candidate and, when nothing else
+// matches, to anonymous credentials. The option only changes which error
+// resolveHostAuth reports internally.
+func WithHelperSoftFail(softFail bool) GetterOption {
+	return func(g *Getter) {
+		g.helperSoftFail = softFail
+	}
+}
+
+// WithHelperRunner overrides how credential helper binaries are invoked.
+// Defaults to actually exec-ing the binary; mainly useful for tests.
+func WithHelperRunner(runner HelperRunner) GetterOption {
+	return func(g *Getter) {
+		g.runHelper = runner
+	}
+}
+
+// HelperRunner execs a docker-credential-<name> helper, returning its
+// stdout. Exported so callers outside this package (and tests) can stub
+// the exec step via WithHelperRunner.
+type HelperRunner func(ctx context.Context, binary string, input string) ([]byte, error)
+
+// Getter looks up registry credentials for a tracked image by walking the
+// Kubernetes secrets it references.
+type Getter struct {
+	implementer k8s.Implementer
+
+	helperPath     string
+	helperTimeout  time.Duration
+	helperSoftFail bool
+	runHelper      HelperRunner
+
+	providers []CredentialProvider
+}
+
+// NewGetter returns a new secrets getter backed by the given Kubernetes
+// implementer.
+func NewGetter(implementer k8s.Implementer, opts ...GetterOption) *Getter {
+	g := &Getter{
+		implementer:   implementer,
+		helperTimeout: defaultHelperTimeout,
+		runHelper:     execHelper,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// hostAuth is a single registry's resolved auth config, merged from
+// whichever secret defined it.
+type hostAuth struct {
+	entry      dockerConfigEntry
+	credHelper string
+}
+
+// Get returns credentials for the given tracked image, falling back to
+// anonymous access if no referenced secret yields a match.
+func (g *Getter) Get(trackedImage *types.TrackedImage) (*Credentials, error) {
+	target := RegistryTarget(trackedImage)
+
+	if creds, ok := g.resolveProvider(trackedImage.Image.Registry()); ok {
+		return creds, nil
+	}
+
+	index := g.index(trackedImage.Namespace, trackedImage.Secrets)
+	if creds, ok := g.resolve(index, target); ok {
+		return creds, nil
+	}
+
+	// fall back to secrets attached directly to pods running this image,
+	// which covers helm-deployed workloads that never populate
+	// TrackedImage.Secrets themselves.
+	pods, err := g.implementer.Pods()
+	if err == nil {
+		podIndex := g.index(trackedImage.Namespace, podImagePullSecrets(pods))
+		if creds, ok := g.resolve(podIndex, target); ok {
+			return creds, nil
+		}
+	}
+
+	return &Credentials{}, nil
+}
+
+// GetAll resolves every credential available to the tracked image's
+// secrets, indexed by normalized registry host, so callers such as the
+// provider/trigger layer can cache lookups instead of re-parsing secrets
+// for every image check. Note this only covers hosts named explicitly by
+// auths/credHelpers; a bare credsStore default (which applies to any host)
+// can't be enumerated this way and is only honoured by resolve.
+func (g *Getter) GetAll(trackedImage *types.TrackedImage) (map[string]*Credentials, error) {
+	index := g.index(trackedImage.Namespace, trackedImage.Secrets)
+
+	resolved := make(map[string]*Credentials, len(index.hosts))
+	for host, auth := range index.hosts {
+		creds, err := g.resolveHostAuth(auth, host)
+		if err != nil {
+			continue
+		}
+		resolved[host] = creds
+	}
+	return resolved, nil
+}
+
+// dockerAuthIndex is a parsed docker config.json/.dockercfg payload, keyed
+// by normalized registry host for the per-host entries it defines, plus the
+// store-wide default credential helper (credsStore) it names, if any.
+// defaultHelper applies to every host, including ones with no auths or
+// credHelpers entry at all.
+type dockerAuthIndex struct {
+	hosts         map[string]hostAuth
+	defaultHelper string
+}
+
+// index parses every referenced secret and merges their auth indexes into
+// one. Later secrets in the list win on host collisions and on defaultHelper.
+func (g *Getter) index(namespace string, secretRefs []string) *dockerAuthIndex {
+	merged := &dockerAuthIndex{hosts: map[string]hostAuth{}}
+
+	for _, secretRef := range secretRefs {
+		secret, err := g.implementer.Secret(namespace, secretRef)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":     err,
+				"namespace": namespace,
+				"secret":    secretRef,
+			}).Warn("secrets.Getter: failed to get secret")
+			continue
+		}
+
+		raw, ok := secret.Data[dockerConfigJSONKey]
+		if !ok {
+			raw, ok = secret.Data[dockerCfgKey]
+		}
+		if !ok {
+			continue
+		}
+
+		secretIndex, err := indexFromConfig(raw)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err,
+				"secret": secretRef,
+			}).Warn("secrets.Getter: failed to parse secret")
+			continue
+		}
+
+		for host, auth := range secretIndex.hosts {
+			merged.hosts[host] = auth
+		}
+		if secretIndex.defaultHelper != "" {
+			merged.defaultHelper = secretIndex.defaultHelper
+		}
+	}
+
+	return merged
+}
+
+// indexFromConfig parses a single docker config.json/.dockercfg payload
+// into a host-keyed auth index. Shared by the Kubernetes secret lookup
+// above and by util/auth's static auth-file source. Hosts are collected
+// from both "auths" and "credHelpers" - a host named only in credHelpers
+// (no matching auths entry) still needs to be indexed so it can resolve.
+func indexFromConfig(raw []byte) (*dockerAuthIndex, error) {
+	cfg, err := parseDockerConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := map[string]hostAuth{}
+	for host, entry := range cfg.Auths {
+		normalized := normalizeRegistryHost(host)
+		auth := hosts[normalized]
+		auth.entry = entry
+		hosts[normalized] = auth
+	}
+	for host, helper := range cfg.CredHelpers {
+		normalized := normalizeRegistryHost(host)
+		auth := hosts[normalized]
+		auth.credHelper = helper
+		hosts[normalized] = auth
+	}
+
+	return &dockerAuthIndex{hosts: hosts, defaultHelper: cfg.CredsStore}, nil
+}
+
+// ResolveFromConfig resolves credentials for host, a registry host or a
+// "<registry>/<repository>" target as built by RegistryTarget (matched by
+// longest prefix), from a raw docker
+// config.json/.dockercfg payload, falling through to a named credential
+// helper when no static entry matches. It is exported so other credential
+// sources (e.g. util/auth's static auth-file source) can reuse the same
+// parsing and credential-helper logic that backs the Kubernetes secret
+// lookup, without needing a Kubernetes implementer.
+func ResolveFromConfig(raw []byte, host string, opts ...GetterOption) (*Credentials, bool, error) {
+	index, err := indexFromConfig(raw)
+	if err != nil {
+		return nil, false, err
+	}
+
+	g := NewGetter(nil, opts...)
+	creds, ok := g.resolve(index, host)
+	return creds, ok, nil
+}
+
+// resolve finds the longest-prefix registry match for target in index and
+// resolves its credentials, falling back to index's store-wide default
+// credential helper (credsStore) when nothing more specific matched.
+func (g *Getter) resolve(index *dockerAuthIndex, target string) (*Credentials, bool) {
+	if auth, host, found := longestPrefixMatch(index.hosts, target); found {
+		if creds, err := g.resolveHostAuth(auth, host); err == nil {
+			return creds, true
+		}
+	}
+
+	if index.defaultHelper != "" {
+		registry := strings.SplitN(target, "/", 2)[0]
+		if creds, err := g.credentialsFromHelper(index.defaultHelper, registry); err == nil {
+			return creds, true
+		}
+	}
+
+	return nil, false
+}
+
+// resolveHostAuth turns a hostAuth into credentials, trying the static auth
+// entry first and falling through to a credential helper.
+func (g *Getter) resolveHostAuth(auth hostAuth, host string) (*Credentials, error) {
+	if creds, err := credentialsFromEntry(auth.entry); err == nil {
+		return creds, nil
+	}
+
+	if auth.credHelper != "" {
+		creds, err := g.credentialsFromHelper(auth.credHelper, host)
+		if err == nil {
+			return creds, nil
+		}
+		if !g.helperSoftFail {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("no usable auth entry for %s", host)
+}
+
+// RegistryTarget builds the string a registry host entry is matched
+// against: "<registry>/<repository>", e.g. "quay.io/myorg/myimage".
+// Exported so other credential sources (e.g. util/auth's static auth-file
+// source) match images the same way the Kubernetes secret lookup does.
+func RegistryTarget(trackedImage *types.TrackedImage) string {
+	registry := trackedImage.Image.Registry()
+	repository := trackedImage.Image.Repository()
+	if repository == "" {
+		return registry
+	}
+	return registry + "/" + repository
+}
+
+// longestPrefixMatch returns the entry in index whose key is the longest
+// registry-path prefix of target, e.g. "quay.io/myorg" beats "quay.io" for
+// target "quay.io/myorg/myimage".
+func longestPrefixMatch(index map[string]hostAuth, target string) (hostAuth, string, bool) {
+	var bestKey string
+	var best hostAuth
+	found := false
+
+	for key := range index {
+		if key != target && !strings.HasPrefix(target, key+"/") {
+			continue
+		}
+		if len(key) > len(bestKey) {
+			bestKey = key
+			best = index[key]
+			found = true
+		}
+	}
+
+	return best, bestKey, found
+}
+
+// normalizeRegistryHost canonicalizes a docker config host key so it can
+// be compared against an image's registry+repository, e.g.
+// "https://index.docker.io/v1/" becomes "docker.io".
+func normalizeRegistryHost(raw string) string {
+	host := raw
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host = strings.TrimSuffix(host, "/v1/")
+	host = strings.TrimSuffix(host, "/")
+	host = strings.TrimSuffix(host, ":443")
+
+	if host == "index.docker.io" {
+		host = "docker.io"
+	}
+
+	return host
+}
+
+// helperReply is the JSON document a docker-credential-* helper prints to
+// stdout in response to a "get" request.
+type helperReply struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// credHelperNamePattern restricts credHelpers/credsStore names to safe
+// docker-credential-<name> binary suffixes. Both values come from secret
+// data/static auth files an attacker may control, so without this check a
+// name like "x/../../../../usr/bin/id" would exec an arbitrary binary.
+var credHelperNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func (g *Getter) credentialsFromHelper(name, registry string) (*Credentials, error) {
+	if !credHelperNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("secrets: invalid credential helper name %q", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.helperTimeout)
+	defer cancel()
+
+	binary := "docker-credential-" + name
+	if g.helperPath != "" {
+		binary = g.helperPath + "/" + binary
+	}
+
+	out, err := g.runHelper(ctx, binary, registry)
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s failed: %s", binary, err)
+	}
+
+	var reply helperReply
+	if err := json.Unmarshal(out, &reply); err != nil {
+		return nil, fmt.Errorf("credential helper %s returned invalid reply: %s", binary, err)
+	}
+
+	return &Credentials{Username: reply.Username, Password: reply.Secret}, nil
+}
+
+// execHelper is the default HelperRunner, exec-ing the helper binary and
+// feeding it the registry URL on stdin as "docker-credential-<name> get" expects.
+func execHelper(ctx context.Context, binary string, registry string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, binary, "get")
+	cmd.Stdin = strings.NewReader(registry)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func credentialsFromEntry(entry dockerConfigEntry) (*Credentials, error) {
+	if entry.Username != "" || entry.Password != "" {
+		return &Credentials{Username: entry.Username, Password: entry.Password}, nil
+	}
+
+	username, password, err := decodeBase64Secret(entry.Auth)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Username: username, Password: password}, nil
+}
+
+// parseDockerConfig accepts both the modern config.json shape (wrapped in
+// "auths", "credHelpers" and/or "credsStore") and the legacy flat
+// .dockercfg shape (host -> entry directly). The shape is told apart by
+// peeking for any of the modern wrapper keys rather than by whether
+// "auths" ended up non-empty, so a credHelpers-only or credsStore-only
+// config.json (no "auths" key at all) is still parsed as modern instead of
+// being misread as a legacy flat map.
+func parseDockerConfig(raw []byte) (*dockerConfigJSON, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config: %s", err)
+	}
+
+	_, hasAuths := probe["auths"]
+	_, hasCredHelpers := probe["credHelpers"]
+	_, hasCredsStore := probe["credsStore"]
+
+	if hasAuths || hasCredHelpers || hasCredsStore {
+		var cfg dockerConfigJSON
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse docker config: %s", err)
+		}
+		return &cfg, nil
+	}
+
+	var flat map[string]dockerConfigEntry
+	if err := json.Unmarshal(raw, &flat); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config: %s", err)
+	}
+	return &dockerConfigJSON{Auths: flat}, nil
+}
+
+// decodeBase64Secret decodes a base64-encoded "user:pass" auth string.
+func decodeBase64Secret(authSecret string) (username, password string, err error) {
+	decoded, err := base64.StdEncoding.DecodeString(authSecret)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode auth secret: %s", err)
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid auth secret format")
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// podImagePullSecrets collects the distinct imagePullSecrets names
+// referenced across a pod list.
+func podImagePullSecrets(pods *v1.PodList) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, pod := range pods.Items {
+		for _, ref := range pod.Spec.ImagePullSecrets {
+			if seen[ref.Name] {
+				continue
+			}
+			seen[ref.Name] = true
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"testing"
@@ -216,6 +217,453 @@ func TestLookupHelmNoSecretsFound(t *testing.T) {
 	}
 }
 
+var credHelperConfigPayload = `{"auths":{"https://my.ecr.registry/v1/":{}},"credHelpers":{"https://my.ecr.registry/v1/":"ecr-login"}}`
+
+// fakeHelperRunner lets tests stub the docker-credential-* exec step without
+// touching a real binary.
+func fakeHelperRunner(reply string, err error) HelperRunner {
+	return func(ctx context.Context, binary string, input string) ([]byte, error) {
+		if err != nil {
+			return nil, err
+		}
+		return []byte(reply), nil
+	}
+}
+
+func TestGetSecretCredentialHelper(t *testing.T) {
+	imgRef, _ := image.Parse("my.ecr.registry/myrepo/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(credHelperConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl)
+	getter.runHelper = fakeHelperRunner(`{"ServerURL":"https://my.ecr.registry/v1/","Username":"AWS","Secret":"ecr-token"}`, nil)
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+
+	if creds.Username != "AWS" {
+		t.Errorf("unexpected username: %s", creds.Username)
+	}
+
+	if creds.Password != "ecr-token" {
+		t.Errorf("unexpected pass: %s", creds.Password)
+	}
+}
+
+func TestGetSecretCredentialHelperSoftFail(t *testing.T) {
+	imgRef, _ := image.Parse("my.ecr.registry/myrepo/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(credHelperConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl, WithHelperSoftFail(true))
+	getter.runHelper = fakeHelperRunner("", fmt.Errorf("exec: \"docker-credential-ecr-login\": executable file not found in $PATH"))
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("soft fail should not surface helper error: %s", err)
+	}
+
+	// should fall back to anonymous rather than erroring out
+	if creds.Username != "" {
+		t.Errorf("expected empty username, got: %s", creds.Username)
+	}
+}
+
+var pathScopedConfigPayload = `{"auths":{"quay.io":{"username":"quay-generic","password":"generic-pass"},"quay.io/myorg":{"username":"quay-myorg","password":"myorg-pass"}}}`
+
+func TestGetPathScopedAuthLongestPrefixMatch(t *testing.T) {
+	imgRef, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(pathScopedConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl)
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret", "anotherregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+
+	// "quay.io/myorg" is a longer prefix match than "quay.io" and should win
+	if creds.Username != "quay-myorg" {
+		t.Errorf("unexpected username: %s, expected longest prefix match to win", creds.Username)
+	}
+
+	if creds.Password != "myorg-pass" {
+		t.Errorf("unexpected pass: %s", creds.Password)
+	}
+}
+
+func TestGetNoMatchingRegistryIsAnonymous(t *testing.T) {
+	imgRef, _ := image.Parse("quay.io/otherorg/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(credHelperConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl)
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	// secret only has auth for my.ecr.registry, image is on quay.io: no match
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if creds.Username != "" {
+		t.Errorf("expected anonymous creds, got username: %s", creds.Username)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	imgRef, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(pathScopedConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl)
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	all, err := getter.GetAll(trackedImage)
+	if err != nil {
+		t.Errorf("failed to get all creds: %s", err)
+	}
+
+	if len(all) != 2 {
+		t.Fatalf("expected 2 indexed registries, got %d", len(all))
+	}
+
+	if all["quay.io/myorg"].Username != "quay-myorg" {
+		t.Errorf("unexpected username for quay.io/myorg: %s", all["quay.io/myorg"].Username)
+	}
+
+	if all["quay.io"].Username != "quay-generic" {
+		t.Errorf("unexpected username for quay.io: %s", all["quay.io"].Username)
+	}
+}
+
+// multiSecretK8sImplementer is a k8s.Implementer fake that, unlike
+// testutil.FakeK8sImplementer, returns a distinct secret per name. It
+// exists to back tests that need two genuinely different secrets merging
+// into one index, rather than the same fixed secret being "merged" with
+// itself.
+type multiSecretK8sImplementer struct {
+	secrets map[string]*v1.Secret
+}
+
+func (f *multiSecretK8sImplementer) Secret(namespace, name string) (*v1.Secret, error) {
+	secret, ok := f.secrets[name]
+	if !ok {
+		return nil, fmt.Errorf("secret not found: %s/%s", namespace, name)
+	}
+	return secret, nil
+}
+
+func (f *multiSecretK8sImplementer) Pods() (*v1.PodList, error) {
+	return &v1.PodList{}, nil
+}
+
+func dockerConfigSecret(payload string) *v1.Secret {
+	return &v1.Secret{
+		Data: map[string][]byte{dockerConfigJSONKey: []byte(payload)},
+		Type: v1.SecretTypeDockercfg,
+	}
+}
+
+func TestGetMergesMultipleDistinctSecrets(t *testing.T) {
+	impl := &multiSecretK8sImplementer{secrets: map[string]*v1.Secret{
+		"generic-secret": dockerConfigSecret(`{"auths":{"quay.io":{"username":"quay-generic","password":"generic-pass"}}}`),
+		"scoped-secret":  dockerConfigSecret(`{"auths":{"quay.io/myorg":{"username":"quay-myorg","password":"myorg-pass"}}}`),
+	}}
+
+	getter := NewGetter(impl)
+
+	scopedImg, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+	scopedTracked := &types.TrackedImage{
+		Image:     scopedImg,
+		Namespace: "default",
+		Secrets:   []string{"generic-secret", "scoped-secret"},
+	}
+
+	// the more specific secret's entry should win for an image under myorg
+	creds, err := getter.Get(scopedTracked)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+	if creds.Username != "quay-myorg" {
+		t.Errorf("unexpected username: %s, expected the merged scoped secret to win", creds.Username)
+	}
+
+	genericImg, _ := image.Parse("quay.io/otherorg/webhook-demo:0.0.11")
+	genericTracked := &types.TrackedImage{
+		Image:     genericImg,
+		Namespace: "default",
+		Secrets:   []string{"generic-secret", "scoped-secret"},
+	}
+
+	// an image outside myorg should fall back to the generic secret's entry
+	creds, err = getter.Get(genericTracked)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+	if creds.Username != "quay-generic" {
+		t.Errorf("unexpected username: %s, expected the generic secret's entry", creds.Username)
+	}
+
+	all, err := getter.GetAll(scopedTracked)
+	if err != nil {
+		t.Errorf("failed to get all creds: %s", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected both secrets' hosts to be merged into the index, got %d entries", len(all))
+	}
+}
+
+// fakeCloudProvider is a minimal CredentialProvider used to test that a
+// Getter tries cloud providers before falling back to k8s secrets.
+type fakeCloudProvider struct {
+	host  string
+	creds *Credentials
+	err   error
+}
+
+func (p *fakeCloudProvider) Matches(host string) bool {
+	return host == p.host
+}
+
+func (p *fakeCloudProvider) Resolve(ctx context.Context, host string) (*Credentials, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.creds, nil
+}
+
+func TestGetCredentialProviderTakesPrecedence(t *testing.T) {
+	imgRef, _ := image.Parse("123456789012.dkr.ecr.us-east-1.amazonaws.com/myimage:latest")
+
+	impl := &testutil.FakeK8sImplementer{
+		Error: fmt.Errorf("should not be called"),
+	}
+
+	provider := &fakeCloudProvider{
+		host:  "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		creds: &Credentials{Username: "AWS", Password: "cloud-token"},
+	}
+
+	getter := NewGetter(impl, WithCredentialProviders(provider))
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+
+	if creds.Username != "AWS" || creds.Password != "cloud-token" {
+		t.Errorf("expected cloud provider creds, got %+v", creds)
+	}
+}
+
+func TestGetCredentialProviderFallsBackOnError(t *testing.T) {
+	imgRef, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(pathScopedConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	provider := &fakeCloudProvider{
+		host: "quay.io",
+		err:  fmt.Errorf("workload identity token exchange failed"),
+	}
+
+	getter := NewGetter(impl, WithCredentialProviders(provider))
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if creds.Username != "quay-myorg" {
+		t.Errorf("expected fallback to k8s secret creds, got username: %s", creds.Username)
+	}
+}
+
+// credHelpersOnlyConfigPayload has no "auths" key at all - every host is
+// resolved purely through credHelpers.
+var credHelpersOnlyConfigPayload = `{"credHelpers":{"my.ecr.registry":"ecr-login"}}`
+
+func TestGetCredHelpersOnlyConfigNoAuthsKey(t *testing.T) {
+	imgRef, _ := image.Parse("my.ecr.registry/myrepo/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(credHelpersOnlyConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl)
+	getter.runHelper = fakeHelperRunner(`{"ServerURL":"my.ecr.registry","Username":"AWS","Secret":"ecr-token"}`, nil)
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+	if creds.Username != "AWS" || creds.Password != "ecr-token" {
+		t.Errorf("unexpected creds: %+v", creds)
+	}
+}
+
+// credsStoreOnlyConfigPayload has no "auths" and no "credHelpers" keys -
+// credsStore must act as a true default for any host.
+var credsStoreOnlyConfigPayload = `{"credsStore":"desktop"}`
+
+func TestGetCredsStoreOnlyConfigAppliesAsDefault(t *testing.T) {
+	imgRef, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(credsStoreOnlyConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl)
+	getter.runHelper = fakeHelperRunner(`{"ServerURL":"quay.io","Username":"desktop-user","Secret":"desktop-pass"}`, nil)
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("failed to get creds: %s", err)
+	}
+	if creds.Username != "desktop-user" || creds.Password != "desktop-pass" {
+		t.Errorf("unexpected creds: %+v", creds)
+	}
+}
+
+func TestGetSecretRejectsUnsafeCredHelperName(t *testing.T) {
+	imgRef, _ := image.Parse("my.ecr.registry/myrepo/webhook-demo:0.0.11")
+
+	maliciousConfigPayload := `{"auths":{"my.ecr.registry":{}},"credHelpers":{"my.ecr.registry":"x/../../../../usr/bin/id"}}`
+
+	impl := &testutil.FakeK8sImplementer{
+		AvailableSecret: &v1.Secret{
+			Data: map[string][]byte{
+				dockerConfigJSONKey: []byte(maliciousConfigPayload),
+			},
+			Type: v1.SecretTypeDockercfg,
+		},
+	}
+
+	getter := NewGetter(impl, WithHelperSoftFail(true))
+	getter.runHelper = fakeHelperRunner("", fmt.Errorf("should never be invoked"))
+
+	trackedImage := &types.TrackedImage{
+		Image:     imgRef,
+		Namespace: "default",
+		Secrets:   []string{"myregistrysecret"},
+	}
+
+	creds, err := getter.Get(trackedImage)
+	if err != nil {
+		t.Errorf("soft fail should not surface the rejected-name error: %s", err)
+	}
+	if creds.Username != "" {
+		t.Errorf("expected anonymous fallback for an unsafe credential helper name, got username: %s", creds.Username)
+	}
+}
+
 func Test_decodeBase64Secret(t *testing.T) {
 	type args struct {
 		authSecret string
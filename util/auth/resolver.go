@@ -0,0 +1,158 @@
+// Package auth unifies registry credential lookup across Keel's various
+// sources (a static auth file, the credential helper it names, and
+// Kubernetes imagePullSecrets) behind a single Resolver, so Keel can run
+// against a local Docker daemon without a Kubernetes cluster.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/rusenask/keel/k8s"
+	"github.com/rusenask/keel/secrets"
+	"github.com/rusenask/keel/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Credentials is re-exported from secrets so Resolver callers don't need
+// to import both packages.
+type Credentials = secrets.Credentials
+
+// authConfigEnv names the environment variable that points at a static
+// auth.json/config.json file to use as a credential source.
+const authConfigEnv = "KEEL_AUTH_CONFIG"
+
+// Request bundles what's needed to resolve credentials for a single image.
+type Request struct {
+	Image *types.TrackedImage
+
+	// SoftFail, when true, means a miss across every source resolves to
+	// anonymous credentials instead of an error. Pollers checking public
+	// images should set this so a missing/unreachable source doesn't fail
+	// the whole check.
+	SoftFail bool
+}
+
+// Source resolves credentials for a single image, reporting whether it
+// had an opinion at all (found) so Resolver can move on to the next
+// source rather than treating "no match" as an error.
+type Source interface {
+	Resolve(ctx context.Context, trackedImage *types.TrackedImage) (creds *Credentials, found bool, err error)
+}
+
+// Resolver composes ordered credential sources: a static auth file (with
+// any credHelpers/credsStore it names), the existing Kubernetes
+// imagePullSecrets lookup, and - unless the request opts out via
+// SoftFail - an error when nothing matches.
+type Resolver struct {
+	sources []Source
+}
+
+// ResolverOption configures a Resolver at construction time.
+type ResolverOption func(*Resolver)
+
+// WithSource appends an additional credential source, tried after any
+// static-auth-file and Kubernetes sources already configured.
+func WithSource(source Source) ResolverOption {
+	return func(r *Resolver) {
+		r.sources = append(r.sources, source)
+	}
+}
+
+// NewResolver builds a Resolver. k8sImplementer may be nil, in which case
+// Keel runs without the Kubernetes imagePullSecrets source, resolving
+// credentials purely from a static auth.json (set via KEEL_AUTH_CONFIG).
+func NewResolver(k8sImplementer k8s.Implementer, opts ...ResolverOption) (*Resolver, error) {
+	r := &Resolver{}
+
+	if path := os.Getenv(authConfigEnv); path != "" {
+		source, err := newStaticFileSource(path)
+		if err != nil {
+			return nil, fmt.Errorf("auth: failed to load %s: %s", path, err)
+		}
+		r.sources = append(r.sources, source)
+	}
+
+	if k8sImplementer != nil {
+		r.sources = append(r.sources, &k8sSecretSource{getter: secrets.NewGetter(k8sImplementer)})
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Resolve tries every configured source in order and returns the first
+// match. If no source matches, Resolve returns anonymous credentials when
+// req.SoftFail is set, and an error otherwise.
+func (r *Resolver) Resolve(ctx context.Context, req Request) (*Credentials, error) {
+	for _, source := range r.sources {
+		creds, found, err := source.Resolve(ctx, req.Image)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"image": req.Image.Image.String(),
+			}).Warn("auth.Resolver: credential source failed")
+			continue
+		}
+		if found {
+			return creds, nil
+		}
+	}
+
+	if req.SoftFail {
+		return &Credentials{}, nil
+	}
+
+	return nil, fmt.Errorf("auth: no credential source resolved %s", req.Image.Image.String())
+}
+
+// staticFileSource resolves credentials from a docker config.json/
+// .dockercfg file loaded once from disk, falling through to any
+// credHelpers/credsStore it names.
+type staticFileSource struct {
+	raw  []byte
+	opts []secrets.GetterOption
+}
+
+// newStaticFileSource reads path once and returns a source for it. Extra
+// GetterOptions (mainly secrets.WithHelperRunner, for tests) are applied
+// alongside the default soft-fail behaviour.
+func newStaticFileSource(path string, opts ...secrets.GetterOption) (*staticFileSource, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &staticFileSource{raw: raw, opts: opts}, nil
+}
+
+func (s *staticFileSource) Resolve(ctx context.Context, trackedImage *types.TrackedImage) (*Credentials, bool, error) {
+	// use the same registry+repository longest-prefix matching as the
+	// Kubernetes secret lookup, so path-scoped entries like "quay.io/myorg"
+	// work consistently across sources.
+	target := secrets.RegistryTarget(trackedImage)
+	opts := append([]secrets.GetterOption{secrets.WithHelperSoftFail(true)}, s.opts...)
+	return secrets.ResolveFromConfig(s.raw, target, opts...)
+}
+
+// k8sSecretSource adapts the existing secrets.Getter (Kubernetes
+// imagePullSecrets lookup) to the Source interface.
+type k8sSecretSource struct {
+	getter *secrets.Getter
+}
+
+func (s *k8sSecretSource) Resolve(ctx context.Context, trackedImage *types.TrackedImage) (*Credentials, bool, error) {
+	creds, err := s.getter.Get(trackedImage)
+	if err != nil {
+		return nil, false, err
+	}
+	if creds.Username == "" && creds.Password == "" {
+		return nil, false, nil
+	}
+	return creds, true, nil
+}
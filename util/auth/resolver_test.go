@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rusenask/keel/types"
+	"github.com/rusenask/keel/util/image"
+)
+
+type fakeSource struct {
+	creds *Credentials
+	found bool
+	err   error
+}
+
+func (s *fakeSource) Resolve(ctx context.Context, trackedImage *types.TrackedImage) (*Credentials, bool, error) {
+	return s.creds, s.found, s.err
+}
+
+func trackedImage(ref string) *types.TrackedImage {
+	imgRef, _ := image.Parse(ref)
+	return &types.TrackedImage{Image: imgRef, Namespace: "default"}
+}
+
+func TestResolverFirstMatchWins(t *testing.T) {
+	r := &Resolver{sources: []Source{
+		&fakeSource{found: false},
+		&fakeSource{found: true, creds: &Credentials{Username: "user-a", Password: "pass-a"}},
+		&fakeSource{found: true, creds: &Credentials{Username: "user-b", Password: "pass-b"}},
+	}}
+
+	creds, err := r.Resolve(context.Background(), Request{Image: trackedImage("karolisr/webhook-demo:0.0.11")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if creds.Username != "user-a" {
+		t.Errorf("expected first matching source to win, got username: %s", creds.Username)
+	}
+}
+
+func TestResolverSkipsFailingSource(t *testing.T) {
+	r := &Resolver{sources: []Source{
+		&fakeSource{err: fmt.Errorf("source unavailable")},
+		&fakeSource{found: true, creds: &Credentials{Username: "user-b", Password: "pass-b"}},
+	}}
+
+	creds, err := r.Resolve(context.Background(), Request{Image: trackedImage("karolisr/webhook-demo:0.0.11")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if creds.Username != "user-b" {
+		t.Errorf("expected resolver to fall through to next source, got username: %s", creds.Username)
+	}
+}
+
+func TestResolverNoMatchErrors(t *testing.T) {
+	r := &Resolver{sources: []Source{&fakeSource{found: false}}}
+
+	_, err := r.Resolve(context.Background(), Request{Image: trackedImage("karolisr/webhook-demo:0.0.11")})
+	if err == nil {
+		t.Error("expected error when no source matches and SoftFail is false")
+	}
+}
+
+func TestResolverNoMatchSoftFailIsAnonymous(t *testing.T) {
+	r := &Resolver{sources: []Source{&fakeSource{found: false}}}
+
+	creds, err := r.Resolve(context.Background(), Request{
+		Image:    trackedImage("karolisr/webhook-demo:0.0.11"),
+		SoftFail: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error with SoftFail set: %s", err)
+	}
+
+	if creds.Username != "" {
+		t.Errorf("expected anonymous creds, got username: %s", creds.Username)
+	}
+}
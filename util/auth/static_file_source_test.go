@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/rusenask/keel/secrets"
+	"github.com/rusenask/keel/types"
+	"github.com/rusenask/keel/util/image"
+)
+
+func writeAuthConfig(t *testing.T, payload string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(payload), 0600); err != nil {
+		t.Fatalf("failed to write auth config fixture: %s", err)
+	}
+	return path
+}
+
+func TestNewStaticFileSourceMissingFile(t *testing.T) {
+	if _, err := newStaticFileSource("/nonexistent/auth.json"); err == nil {
+		t.Error("expected an error for a missing auth config file")
+	}
+}
+
+func TestStaticFileSourcePathScopedAuth(t *testing.T) {
+	path := writeAuthConfig(t, `{"auths":{"quay.io":{"username":"generic","password":"generic-pass"},"quay.io/myorg":{"username":"myorg-user","password":"myorg-pass"}}}`)
+
+	source, err := newStaticFileSource(path)
+	if err != nil {
+		t.Fatalf("failed to create static file source: %s", err)
+	}
+
+	imgRef, _ := image.Parse("quay.io/myorg/webhook-demo:0.0.11")
+	trackedImage := &types.TrackedImage{Image: imgRef, Namespace: "default"}
+
+	creds, found, err := source.Resolve(context.Background(), trackedImage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !found {
+		t.Fatal("expected a match")
+	}
+
+	// "quay.io/myorg" is a longer prefix match than "quay.io" and should win,
+	// matching the k8s-secret path's longest-prefix behavior.
+	if creds.Username != "myorg-user" {
+		t.Errorf("unexpected username: %s, expected longest prefix match to win", creds.Username)
+	}
+}
+
+func TestStaticFileSourceCredentialHelperFallthrough(t *testing.T) {
+	path := writeAuthConfig(t, `{"auths":{"my.ecr.registry":{}},"credHelpers":{"my.ecr.registry":"ecr-login"}}`)
+
+	fakeRunner := secrets.HelperRunner(func(ctx context.Context, binary, input string) ([]byte, error) {
+		return []byte(`{"ServerURL":"my.ecr.registry","Username":"AWS","Secret":"ecr-token"}`), nil
+	})
+
+	source, err := newStaticFileSource(path, secrets.WithHelperRunner(fakeRunner))
+	if err != nil {
+		t.Fatalf("failed to create static file source: %s", err)
+	}
+
+	imgRef, _ := image.Parse("my.ecr.registry/myimage:latest")
+	trackedImage := &types.TrackedImage{Image: imgRef, Namespace: "default"}
+
+	creds, found, err := source.Resolve(context.Background(), trackedImage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !found {
+		t.Fatal("expected a match via the credential helper")
+	}
+
+	if creds.Username != "AWS" || creds.Password != "ecr-token" {
+		t.Errorf("unexpected creds: %+v", creds)
+	}
+}
+
+func TestStaticFileSourceNoMatch(t *testing.T) {
+	path := writeAuthConfig(t, `{"auths":{"quay.io/myorg":{"username":"myorg-user","password":"myorg-pass"}}}`)
+
+	source, err := newStaticFileSource(path)
+	if err != nil {
+		t.Fatalf("failed to create static file source: %s", err)
+	}
+
+	imgRef, _ := image.Parse("docker.io/library/webhook-demo:0.0.11")
+	trackedImage := &types.TrackedImage{Image: imgRef, Namespace: "default"}
+
+	_, found, err := source.Resolve(context.Background(), trackedImage)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if found {
+		t.Error("expected no match for an unrelated registry")
+	}
+}